@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// User is a Slack workspace member who has connected a music provider so
+// their status can be kept in sync with what they're listening to.
+type User struct {
+	SlackUserID      string
+	SlackAccessToken string
+
+	// Provider discriminates which MusicProvider this user authenticated
+	// with, e.g. "spotify", "apple_music", "youtube_music", "mpd".
+	Provider string
+	// ProviderCreds is the provider-specific credential blob (access token,
+	// refresh token, expiry, or whatever else that provider's Token needs),
+	// stored as JSON so each provider can evolve its own shape independently.
+	ProviderCreds []byte
+
+	SpotifyAccessToken  string
+	SpotifyRefreshToken string
+	SpotifyExpiry       time.Time
+	SpotifyTokenType    string
+}
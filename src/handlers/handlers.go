@@ -2,92 +2,257 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/o-mago/spotify-status/src/app_error"
 	"github.com/o-mago/spotify-status/src/domain"
+	"github.com/o-mago/spotify-status/src/metrics"
+	"github.com/o-mago/spotify-status/src/oauthstate"
+	"github.com/o-mago/spotify-status/src/providers"
+	"github.com/o-mago/spotify-status/src/reqctx"
 	"github.com/o-mago/spotify-status/src/services"
+	"github.com/o-mago/spotify-status/src/session"
+	"github.com/o-mago/spotify-status/src/spotifyauth"
 	"github.com/zmb3/spotify"
+	"golang.org/x/oauth2"
 )
 
+// oauthStateTTL bounds how long a /login-minted state (and the PKCE
+// verifier it carries) stays valid before SpotifyCallbackHandler must
+// reject it.
+const oauthStateTTL = 10 * time.Minute
+
 type handlers struct {
+	logger               *slog.Logger
 	services             services.Services
 	spotifyAuthenticator spotify.Authenticator
-	spotifyState         string
+	spotifyClientID      string
+	spotifyRedirectURL   string
+	stateStore           oauthstate.StateStore
+	sessionStore         session.Store
+	providers            providers.Registry
+	tokenRefresher       spotifyauth.TokenRefresher
 	slackClientID        string
 	slackClientSecret    string
 	slackAuthURL         string
+	slackSigningSecret   string
 }
 
 type Handlers interface {
 	HealthHandler(w http.ResponseWriter, r *http.Request)
 	SpotifyCallbackHandler(w http.ResponseWriter, r *http.Request)
 	SlackCallbackHandler(w http.ResponseWriter, r *http.Request)
+	ProviderLoginHandler(w http.ResponseWriter, r *http.Request)
+	ProviderCallbackHandler(w http.ResponseWriter, r *http.Request)
+	SlackEventsHandler(w http.ResponseWriter, r *http.Request)
+	SlackCommandHandler(w http.ResponseWriter, r *http.Request)
 
 	writeResponse(w http.ResponseWriter, resp interface{}, status int)
 }
 
-func NewHandlers(services services.Services, spotifyAuthenticator spotify.Authenticator, spotifyState, slackClientID, slackClientSecret, slackAuthURL string) Handlers {
+func NewHandlers(logger *slog.Logger, services services.Services, spotifyAuthenticator spotify.Authenticator, spotifyClientID, spotifyRedirectURL string, sessionStore session.Store, registry providers.Registry, tokenRefresher spotifyauth.TokenRefresher, slackClientID, slackClientSecret, slackAuthURL, slackSigningSecret string) Handlers {
 	return handlers{
+		logger,
 		services,
 		spotifyAuthenticator,
-		spotifyState,
+		spotifyClientID,
+		spotifyRedirectURL,
+		oauthstate.NewMemoryStateStore(oauthStateTTL),
+		sessionStore,
+		registry,
+		tokenRefresher,
 		slackClientID,
 		slackClientSecret,
 		slackAuthURL,
+		slackSigningSecret,
 	}
 }
 
+// logAppError logs an app_error.AppError as a structured event carrying the
+// request id, the time elapsed since the request started, and whatever else
+// the caller already knows about the request (user id, provider, ...).
+func (h handlers) logAppError(r *http.Request, err error, appError app_error.AppError, fields ...any) {
+	args := append([]any{
+		"request_id", reqctx.RequestID(r.Context()),
+		"app_error_code", appError.Code,
+		"latency_ms", reqctx.LatencyMillis(r.Context()),
+		"err", err,
+	}, fields...)
+	h.logger.Error(appError.Message, args...)
+}
+
 func (h handlers) writeResponse(w http.ResponseWriter, resp interface{}, status int) {
 	w.WriteHeader(status)
 	w.Header().Set("Content-Type", "application/json")
 	jsonResp, err := json.Marshal(resp)
 	if err != nil {
-		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+		h.logger.Error("failed to marshal response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 	w.Write(jsonResp)
 }
 
 func (h handlers) HealthHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("New Relic ok")
-	fmt.Fprintf(w, "OK")
+	w.Write([]byte("OK"))
 }
 
-func (h handlers) SpotifyCallbackHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+func (h handlers) ProviderLoginHandler(w http.ResponseWriter, r *http.Request) {
+	providerName := mux.Vars(r)["provider"]
 
-	userID, err := r.Cookie("user_id")
+	provider, ok := h.providers.Get(providerName)
+	if !ok {
+		appError := app_error.UnknownProvider
+		h.logAppError(r, nil, appError, "provider", providerName)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	sess, ok := h.sessionStore.Get(r)
+	if !ok {
+		appError := app_error.InvalidCookie
+		h.logAppError(r, nil, appError, "provider", providerName)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	codeVerifier, err := oauthstate.NewPKCEVerifier()
 	if err != nil {
+		appError := app_error.SlackAuthBadRequest
+		h.logAppError(r, err, appError, "user_id", sess.SlackUserID, "provider", providerName)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	state, err := h.stateStore.New(sess.SlackUserID, codeVerifier)
+	if err != nil {
+		appError := app_error.SlackAuthBadRequest
+		h.logAppError(r, err, appError, "user_id", sess.SlackUserID, "provider", providerName)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	authURL := provider.AuthURL(state, oauthstate.PKCEChallengeS256(codeVerifier))
+	if authURL == "" {
+		appError := app_error.ProviderNotImplemented
+		h.logAppError(r, nil, appError, "user_id", sess.SlackUserID, "provider", providerName)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusSeeOther)
+}
+
+// ProviderCallbackHandler is the shared callback for every MusicProvider,
+// registered at /auth/{provider}/callback. It exchanges the auth code,
+// records which provider the user picked, and stashes the resulting
+// credentials on the user so the status-updater service can dispatch on
+// domain.User.Provider later.
+func (h handlers) ProviderCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	providerName := mux.Vars(r)["provider"]
+
+	provider, ok := h.providers.Get(providerName)
+	if !ok {
+		appError := app_error.UnknownProvider
+		h.logAppError(r, nil, appError, "provider", providerName)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	sess, ok := h.sessionStore.Get(r)
+	if !ok {
 		appError := app_error.InvalidCookie
-		fmt.Println(err, appError)
+		h.logAppError(r, nil, appError, "provider", providerName)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	stateEntry, err := h.stateStore.Consume(state)
+	if err != nil || stateEntry.SlackUserID != sess.SlackUserID {
+		appError := app_error.InvalidOAuthState
+		h.logAppError(r, err, appError, "user_id", sess.SlackUserID, "provider", providerName)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	token, err := provider.Exchange(ctx, r, stateEntry.CodeVerifier)
+	if err != nil {
+		appError := app_error.InvalidSpotifyAuthCode
+		h.logAppError(r, err, appError, "user_id", sess.SlackUserID, "provider", providerName)
 		h.writeResponse(w, appError.Error(), appError.Status())
 		return
 	}
-	slackAccessToken, err := r.Cookie("slack_access_token")
+
+	creds, err := json.Marshal(token)
 	if err != nil {
+		appError := app_error.AddUserError
+		h.logAppError(r, err, appError, "user_id", sess.SlackUserID, "provider", providerName)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	user := domain.User{
+		SlackUserID:      sess.SlackUserID,
+		SlackAccessToken: sess.SlackAccessToken,
+		Provider:         providerName,
+		ProviderCreds:    creds,
+	}
+
+	if err := h.services.AddUser(ctx, user); err != nil {
+		appError := app_error.AddUserError
+		h.logAppError(r, err, appError, "user_id", sess.SlackUserID, "provider", providerName)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	http.ServeFile(w, r, "./static/completed/index.html")
+}
+
+func (h handlers) SpotifyCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sess, ok := h.sessionStore.Get(r)
+	if !ok {
 		appError := app_error.InvalidCookie
-		fmt.Println(err, appError)
+		h.logAppError(r, nil, appError)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		metrics.SpotifyCallbackTotal.WithLabelValues("invalid_cookie").Inc()
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	stateEntry, err := h.stateStore.Consume(state)
+	if err != nil || stateEntry.SlackUserID != sess.SlackUserID {
+		appError := app_error.InvalidOAuthState
+		h.logAppError(r, err, appError, "user_id", sess.SlackUserID)
 		h.writeResponse(w, appError.Error(), appError.Status())
+		metrics.SpotifyCallbackTotal.WithLabelValues("invalid_state").Inc()
 		return
 	}
 
-	spotifyToken, err := h.spotifyAuthenticator.Token(h.spotifyState, r)
+	exchangeStart := time.Now()
+	spotifyToken, err := h.spotifyPKCEConfig().Exchange(ctx, r.URL.Query().Get("code"),
+		oauth2.SetAuthURLParam("code_verifier", stateEntry.CodeVerifier))
+	metrics.ObserveOutbound("spotify", exchangeStart)
 	if err != nil {
 		appError := app_error.InvalidSpotifyAuthCode
-		fmt.Println(err, appError)
+		h.logAppError(r, err, appError, "user_id", sess.SlackUserID)
 		h.writeResponse(w, appError.Error(), appError.Status())
+		metrics.SpotifyCallbackTotal.WithLabelValues("invalid_auth_code").Inc()
 		return
 	}
 
 	user := domain.User{
-		SlackUserID:         userID.Value,
-		SlackAccessToken:    slackAccessToken.Value,
+		SlackUserID:         sess.SlackUserID,
+		SlackAccessToken:    sess.SlackAccessToken,
 		SpotifyAccessToken:  spotifyToken.AccessToken,
 		SpotifyRefreshToken: spotifyToken.RefreshToken,
 		SpotifyExpiry:       spotifyToken.Expiry,
@@ -97,14 +262,30 @@ func (h handlers) SpotifyCallbackHandler(w http.ResponseWriter, r *http.Request)
 	err = h.services.AddUser(ctx, user)
 	if err != nil {
 		appError := app_error.AddUserError
-		fmt.Println(err, appError)
+		h.logAppError(r, err, appError, "user_id", sess.SlackUserID)
 		h.writeResponse(w, appError.Error(), appError.Status())
+		metrics.SpotifyCallbackTotal.WithLabelValues("add_user_error").Inc()
 		return
 	}
 
+	metrics.SpotifyCallbackTotal.WithLabelValues("success").Inc()
 	http.ServeFile(w, r, "./static/completed/index.html")
 }
 
+// spotifyPKCEConfig builds an oauth2.Config for the authorization code
+// exchange that carries a PKCE code verifier instead of the client secret
+// used by h.spotifyAuthenticator.
+func (h handlers) spotifyPKCEConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:    h.spotifyClientID,
+		RedirectURL: h.spotifyRedirectURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  spotify.AuthURL,
+			TokenURL: spotify.TokenURL,
+		},
+	}
+}
+
 func (h handlers) SlackCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	slackCode := r.URL.Query().Get("code")
 
@@ -113,11 +294,14 @@ func (h handlers) SlackCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	requestBody.Set("client_id", h.slackClientID)
 	requestBody.Set("client_secret", h.slackClientSecret)
 
+	outboundStart := time.Now()
 	resp, err := http.Post(h.slackAuthURL, "application/x-www-form-urlencoded", strings.NewReader(requestBody.Encode()))
+	metrics.ObserveOutbound("slack", outboundStart)
 	if err != nil {
 		appError := app_error.SlackAuthBadRequest
-		fmt.Println(err, appError)
+		h.logAppError(r, err, appError)
 		h.writeResponse(w, appError.Error(), appError.Status())
+		metrics.SlackCallbackTotal.WithLabelValues("request_error").Inc()
 		return
 	}
 
@@ -126,8 +310,9 @@ func (h handlers) SlackCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		appError := app_error.SlackAuthBadRequest
-		fmt.Println(err, appError)
+		h.logAppError(r, err, appError)
 		h.writeResponse(w, appError.Error(), appError.Status())
+		metrics.SlackCallbackTotal.WithLabelValues("request_error").Inc()
 		return
 	}
 
@@ -149,18 +334,44 @@ func (h handlers) SlackCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	err = json.Unmarshal(body, &slackAuthResponse)
 	if err != nil {
 		appError := app_error.SlackAuthBadRequest
-		fmt.Println(err, appError)
+		h.logAppError(r, err, appError)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		metrics.SlackCallbackTotal.WithLabelValues("request_error").Inc()
+		return
+	}
+
+	err = h.sessionStore.Save(w, r, session.Data{
+		SlackUserID:      slackAuthResponse.AuthedUser.Id,
+		SlackAccessToken: slackAuthResponse.AuthedUser.AccessToken,
+	})
+	if err != nil {
+		appError := app_error.SlackAuthBadRequest
+		h.logAppError(r, err, appError, "user_id", slackAuthResponse.AuthedUser.Id)
 		h.writeResponse(w, appError.Error(), appError.Status())
+		metrics.SlackCallbackTotal.WithLabelValues("session_error").Inc()
 		return
 	}
 
-	expiration := time.Now().Add(1 * time.Hour)
-	cookieUser := http.Cookie{Name: "user_id", Value: slackAuthResponse.AuthedUser.Id, Expires: expiration}
-	cookieSlack := http.Cookie{Name: "slack_access_token", Value: slackAuthResponse.AuthedUser.AccessToken, Expires: expiration}
-	http.SetCookie(w, &cookieUser)
-	http.SetCookie(w, &cookieSlack)
+	codeVerifier, err := oauthstate.NewPKCEVerifier()
+	if err != nil {
+		appError := app_error.SlackAuthBadRequest
+		h.logAppError(r, err, appError, "user_id", slackAuthResponse.AuthedUser.Id)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		metrics.SlackCallbackTotal.WithLabelValues("pkce_error").Inc()
+		return
+	}
+
+	state, err := h.stateStore.New(slackAuthResponse.AuthedUser.Id, codeVerifier)
+	if err != nil {
+		appError := app_error.SlackAuthBadRequest
+		h.logAppError(r, err, appError, "user_id", slackAuthResponse.AuthedUser.Id)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		metrics.SlackCallbackTotal.WithLabelValues("pkce_error").Inc()
+		return
+	}
 
-	spotifyAuthURL := h.spotifyAuthenticator.AuthURL(h.spotifyState)
+	spotifyAuthURL := oauthstate.AppendPKCEChallenge(h.spotifyAuthenticator.AuthURL(state), oauthstate.PKCEChallengeS256(codeVerifier))
 
+	metrics.SlackCallbackTotal.WithLabelValues("success").Inc()
 	http.Redirect(w, r, spotifyAuthURL, http.StatusSeeOther)
 }
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/o-mago/spotify-status/src/app_error"
+	"github.com/o-mago/spotify-status/src/domain"
+	"github.com/o-mago/spotify-status/src/providers"
+	"github.com/o-mago/spotify-status/src/slackverify"
+)
+
+// SlackCommandHandler handles the `/spotify-status` slash command: pause,
+// resume, now and disconnect. It's registered on Slack's "Request URL" for
+// the command and must respond within Slack's 3s budget.
+func (h handlers) SlackCommandHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		appError := app_error.SlackAuthBadRequest
+		h.logAppError(r, err, appError)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	if err := slackverify.Verify(h.slackSigningSecret, r, body); err != nil {
+		appError := app_error.InvalidSlackSignature
+		h.logAppError(r, err, appError)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		appError := app_error.SlackAuthBadRequest
+		h.logAppError(r, err, appError)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	slackUserID := form.Get("user_id")
+	subcommand := strings.TrimSpace(form.Get("text"))
+
+	var reply string
+	switch subcommand {
+	case "pause":
+		err = h.services.PauseStatusUpdates(ctx, slackUserID)
+		reply = "Status updates paused."
+	case "resume":
+		err = h.services.ResumeStatusUpdates(ctx, slackUserID)
+		reply = "Status updates resumed."
+	case "disconnect":
+		err = h.services.DeleteUserBySlackID(ctx, slackUserID)
+		reply = "Disconnected your music provider. Sign in again to reconnect."
+	case "now":
+		reply, err = h.nowPlayingReply(ctx, slackUserID)
+	default:
+		reply = "Usage: /spotify-status pause|resume|now|disconnect"
+	}
+
+	if err != nil {
+		appError := app_error.SlackCommandError
+		h.logAppError(r, err, appError, "user_id", slackUserID, "command", subcommand)
+		reply = "Sorry, something went wrong handling that command."
+	}
+
+	h.writeResponse(w, slackEphemeralMessage{ResponseType: "ephemeral", Text: reply}, http.StatusOK)
+}
+
+func (h handlers) nowPlayingReply(ctx context.Context, slackUserID string) (string, error) {
+	user, err := h.services.GetUserBySlackID(ctx, slackUserID)
+	if err != nil {
+		return "", err
+	}
+
+	providerName := user.Provider
+	if providerName == "" {
+		providerName = "spotify"
+	}
+	provider, ok := h.providers.Get(providerName)
+	if !ok {
+		return "", app_error.UnknownProvider
+	}
+
+	token, err := h.providerToken(ctx, providerName, user)
+	if err != nil {
+		return "", err
+	}
+
+	track, err := provider.NowPlaying(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	if track.IsPaused {
+		return "Nothing is currently playing.", nil
+	}
+	return "Now playing: " + track.Title + " — " + track.Artist, nil
+}
+
+// providerToken resolves the Token to pass to MusicProvider.NowPlaying. For
+// Spotify it goes through the same refresh-aware oauth2.TokenSource Client
+// wraps, so the token is only refreshed when it's actually close to expiry;
+// for every other provider it decodes user.ProviderCreds, the JSON of the
+// providers.Token that ProviderCallbackHandler stored.
+func (h handlers) providerToken(ctx context.Context, providerName string, user domain.User) (*providers.Token, error) {
+	if providerName == "spotify" {
+		token, err := h.tokenRefresher.Token(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+
+		return &providers.Token{
+			AccessToken:  token.AccessToken,
+			RefreshToken: token.RefreshToken,
+			TokenType:    token.TokenType,
+			Expiry:       token.Expiry,
+		}, nil
+	}
+
+	var token providers.Token
+	if err := json.Unmarshal(user.ProviderCreds, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+type slackEphemeralMessage struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// SlackEventsHandler handles Slack's Events API subscription: URL
+// verification challenges, and app_uninstalled/tokens_revoked events that
+// must cascade-delete the user's stored tokens.
+func (h handlers) SlackEventsHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		appError := app_error.SlackAuthBadRequest
+		h.logAppError(r, err, appError)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	if err := slackverify.Verify(h.slackSigningSecret, r, body); err != nil {
+		appError := app_error.InvalidSlackSignature
+		h.logAppError(r, err, appError)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	var envelope struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Event     struct {
+			Type string `json:"type"`
+			User string `json:"user"`
+		} `json:"event"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		appError := app_error.SlackAuthBadRequest
+		h.logAppError(r, err, appError)
+		h.writeResponse(w, appError.Error(), appError.Status())
+		return
+	}
+
+	if envelope.Type == "url_verification" {
+		h.writeResponse(w, struct {
+			Challenge string `json:"challenge"`
+		}{envelope.Challenge}, http.StatusOK)
+		return
+	}
+
+	switch envelope.Event.Type {
+	case "app_uninstalled", "tokens_revoked":
+		if err := h.services.DeleteUserBySlackID(r.Context(), envelope.Event.User); err != nil {
+			appError := app_error.DeleteUserError
+			h.logAppError(r, err, appError, "user_id", envelope.Event.User)
+			h.writeResponse(w, appError.Error(), appError.Status())
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	SpotifyCallbackTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "spotify_callback_total",
+		Help: "Count of Spotify OAuth callback requests by result.",
+	}, []string{"result"})
+
+	SlackCallbackTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "slack_callback_total",
+		Help: "Count of Slack OAuth callback requests by result.",
+	}, []string{"result"})
+
+	TokenRefreshTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "token_refresh_total",
+		Help: "Count of Spotify token refresh attempts by result.",
+	}, []string{"result"})
+
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "handler_duration_seconds",
+		Help:    "Latency of HTTP handlers.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler"})
+
+	OutboundCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "outbound_call_duration_seconds",
+		Help:    "Latency of outbound calls to Spotify/Slack.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"target"})
+)
+
+// ObserveOutbound is a small helper for timing a single outbound call, used
+// as `defer metrics.ObserveOutbound("spotify", time.Now())`.
+func ObserveOutbound(target string, start time.Time) {
+	OutboundCallDuration.WithLabelValues(target).Observe(time.Since(start).Seconds())
+}
+
+// Handler exposes the Prometheus registry at /metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o-mago/spotify-status/src/metrics"
+	"github.com/o-mago/spotify-status/src/reqctx"
+)
+
+// RequestID attaches a per-request id to the request context and echoes it
+// back as X-Request-Id, so a single id ties together a client report, a log
+// line, and a trace.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-Id")
+		if id == "" {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set("X-Request-Id", id)
+		ctx := reqctx.WithRequestID(r.Context(), id)
+		ctx = reqctx.WithStartTime(ctx, time.Now())
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Metrics records handler latency into metrics.HandlerDuration, labelled
+// with the route name passed in at registration time.
+func Metrics(handlerName string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		metrics.HandlerDuration.WithLabelValues(handlerName).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,122 @@
+package oauthstate
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("oauth state not found or expired")
+
+// Entry binds a single login attempt's CSRF state to the Slack user that
+// started it and the PKCE code verifier that must match the token exchange.
+type Entry struct {
+	SlackUserID  string
+	CodeVerifier string
+	CreatedAt    time.Time
+}
+
+// StateStore mints and validates the per-request OAuth state values used to
+// protect the Spotify authorization code flow against CSRF and code replay.
+type StateStore interface {
+	New(slackUserID, codeVerifier string) (string, error)
+	// Consume validates state, deletes it so it cannot be replayed, and
+	// returns the entry it was bound to. Returns ErrNotFound if the state is
+	// unknown or has expired.
+	Consume(state string) (Entry, error)
+}
+
+type memoryStateStore struct {
+	ttl     time.Duration
+	entries sync.Map
+}
+
+// NewMemoryStateStore returns a StateStore backed by sync.Map. Entries older
+// than ttl are treated as not found by Consume.
+func NewMemoryStateStore(ttl time.Duration) StateStore {
+	return &memoryStateStore{ttl: ttl}
+}
+
+func (s *memoryStateStore) New(slackUserID, codeVerifier string) (string, error) {
+	s.reapExpired()
+
+	state, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	s.entries.Store(state, Entry{
+		SlackUserID:  slackUserID,
+		CodeVerifier: codeVerifier,
+		CreatedAt:    time.Now(),
+	})
+
+	return state, nil
+}
+
+// reapExpired evicts entries older than ttl so a login that's started but
+// never completes (abandoned tab, bot traffic) doesn't leak its Entry —
+// including its PKCE verifier — for the life of the process.
+func (s *memoryStateStore) reapExpired() {
+	now := time.Now()
+	s.entries.Range(func(key, value any) bool {
+		if now.Sub(value.(Entry).CreatedAt) > s.ttl {
+			s.entries.Delete(key)
+		}
+		return true
+	})
+}
+
+func (s *memoryStateStore) Consume(state string) (Entry, error) {
+	value, ok := s.entries.LoadAndDelete(state)
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+
+	entry := value.(Entry)
+	if time.Since(entry.CreatedAt) > s.ttl {
+		return Entry{}, ErrNotFound
+	}
+
+	return entry, nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewPKCEVerifier returns a random, high-entropy code verifier suitable for
+// the PKCE S256 flow (RFC 7636 recommends 43-128 characters).
+func NewPKCEVerifier() (string, error) {
+	return randomString(64)
+}
+
+// PKCEChallengeS256 derives the S256 code challenge for a given verifier.
+func PKCEChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AppendPKCEChallenge appends the S256 code_challenge query parameters PKCE
+// requires onto authURL, returning authURL unchanged if it fails to parse.
+func AppendPKCEChallenge(authURL, codeChallenge string) string {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		return authURL
+	}
+
+	query := parsed.Query()
+	query.Set("code_challenge_method", "S256")
+	query.Set("code_challenge", codeChallenge)
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
@@ -0,0 +1,36 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// AppleMusicProvider is a scaffold: Apple Music has no OAuth2 authorization
+// code flow. Access instead requires a developer token (a JWT signed with
+// the app's private key, valid up to 6 months) combined with a MusicKit
+// user token obtained client-side via MusicKit JS/native SDKs. AuthURL and
+// Exchange are left unimplemented until that handshake is wired up.
+type AppleMusicProvider struct {
+	developerToken string
+}
+
+func NewAppleMusicProvider(developerToken string) AppleMusicProvider {
+	return AppleMusicProvider{developerToken: developerToken}
+}
+
+func (AppleMusicProvider) Name() string { return "apple_music" }
+
+func (AppleMusicProvider) AuthURL(state, codeChallenge string) string {
+	// Apple Music has no redirect-based auth URL; the MusicKit user token is
+	// requested client-side and posted back to /auth/apple_music/callback.
+	return ""
+}
+
+func (AppleMusicProvider) Exchange(ctx context.Context, r *http.Request, codeVerifier string) (*Token, error) {
+	return nil, errors.New("apple_music: not implemented, requires a MusicKit user token from the client")
+}
+
+func (AppleMusicProvider) NowPlaying(ctx context.Context, token *Token) (Track, error) {
+	return Track{}, errors.New("apple_music: not implemented")
+}
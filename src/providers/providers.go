@@ -0,0 +1,59 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Track is the provider-agnostic shape the status-updater service works
+// with, regardless of which MusicProvider produced it.
+type Track struct {
+	Title    string
+	Artist   string
+	IsPaused bool
+}
+
+// Token is the provider-agnostic credential result of an OAuth exchange.
+// Providers that don't use OAuth (MPD) return a Token with its fields empty.
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	TokenType    string
+	Expiry       time.Time
+}
+
+// MusicProvider is implemented by every music source the status-updater can
+// pull "now playing" information from.
+type MusicProvider interface {
+	// Name is the discriminator stored on domain.User.Provider and used in
+	// the /auth/{provider}/callback route.
+	Name() string
+	// AuthURL builds the authorization URL for state. codeChallenge is the
+	// PKCE S256 challenge derived from the verifier the caller stored
+	// alongside state; providers that don't support PKCE ignore it.
+	AuthURL(state, codeChallenge string) string
+	// Exchange trades the authorization code in r for a Token. codeVerifier
+	// is the PKCE verifier bound to the state that was consumed to reach
+	// this callback; providers that don't support PKCE ignore it.
+	Exchange(ctx context.Context, r *http.Request, codeVerifier string) (*Token, error)
+	NowPlaying(ctx context.Context, token *Token) (Track, error)
+}
+
+// Registry looks up a MusicProvider by the name used in its callback route.
+type Registry struct {
+	providers map[string]MusicProvider
+}
+
+func NewRegistry(providers ...MusicProvider) Registry {
+	byName := make(map[string]MusicProvider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+	return Registry{providers: byName}
+}
+
+func (r Registry) Get(name string) (MusicProvider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/o-mago/spotify-status/src/oauthstate"
+	"github.com/zmb3/spotify"
+	"golang.org/x/oauth2"
+)
+
+// SpotifyProvider wraps the existing PKCE-based Spotify authenticator so it
+// fits the MusicProvider interface alongside the other providers.
+type SpotifyProvider struct {
+	authenticator spotify.Authenticator
+	oauthConfig   *oauth2.Config
+}
+
+func NewSpotifyProvider(authenticator spotify.Authenticator, oauthConfig *oauth2.Config) SpotifyProvider {
+	return SpotifyProvider{authenticator: authenticator, oauthConfig: oauthConfig}
+}
+
+func (SpotifyProvider) Name() string { return "spotify" }
+
+func (p SpotifyProvider) AuthURL(state, codeChallenge string) string {
+	return oauthstate.AppendPKCEChallenge(p.authenticator.AuthURL(state), codeChallenge)
+}
+
+func (p SpotifyProvider) Exchange(ctx context.Context, r *http.Request, codeVerifier string) (*Token, error) {
+	token, err := p.oauthConfig.Exchange(ctx, r.URL.Query().Get("code"),
+		oauth2.SetAuthURLParam("code_verifier", codeVerifier))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Token{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		TokenType:    token.TokenType,
+		Expiry:       token.Expiry,
+	}, nil
+}
+
+func (p SpotifyProvider) NowPlaying(ctx context.Context, token *Token) (Track, error) {
+	client := p.authenticator.NewClient(&oauth2.Token{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		TokenType:    token.TokenType,
+		Expiry:       token.Expiry,
+	})
+
+	playing, err := client.PlayerCurrentlyPlaying()
+	if err != nil {
+		return Track{}, err
+	}
+
+	track := Track{IsPaused: !playing.Playing}
+	if playing.Item != nil {
+		track.Title = playing.Item.Name
+		if len(playing.Item.Artists) > 0 {
+			track.Artist = playing.Item.Artists[0].Name
+		}
+	}
+
+	return track, nil
+}
@@ -0,0 +1,38 @@
+package reqctx
+
+import (
+	"context"
+	"time"
+)
+
+type requestIDKey struct{}
+type startTimeKey struct{}
+
+// WithRequestID returns a context carrying id, retrievable via RequestID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request id stashed by the request-id middleware, or
+// "" if none is present (e.g. in a test calling the handler directly).
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithStartTime returns a context carrying the time the request started
+// being handled, retrievable via LatencyMillis.
+func WithStartTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, startTimeKey{}, t)
+}
+
+// LatencyMillis returns the elapsed time since the request-id middleware
+// stashed its start time, in milliseconds. Returns 0 if no start time is
+// present (e.g. in a test calling the handler directly).
+func LatencyMillis(ctx context.Context) int64 {
+	start, ok := ctx.Value(startTimeKey{}).(time.Time)
+	if !ok {
+		return 0
+	}
+	return time.Since(start).Milliseconds()
+}
@@ -0,0 +1,118 @@
+package session
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/securecookie"
+)
+
+const redisKeyPrefix = "spotify-status:session:"
+const redisSessionTTL = 30 * 24 * time.Hour
+
+// redisStore keeps the session payload in Redis, AES-GCM encrypted at rest,
+// and only ever puts a securecookie-signed, random session id in the
+// browser cookie. It's the horizontally-scalable alternative to cookieStore
+// for deployments running more than one instance.
+type redisStore struct {
+	client *redis.Client
+	cookie *securecookie.SecureCookie
+	key    encryptionKey
+}
+
+// NewRedisStore returns a Store backed by Redis. cookieHashKey/cookieBlockKey
+// sign and encrypt the session id cookie (same SESSION_SECRET-derived keys
+// as cookieStore); dataKey AES-GCM-encrypts the payload written to Redis and
+// should come from a KMS-provided data-encryption key.
+func NewRedisStore(client *redis.Client, cookieHashKey, cookieBlockKey, dataKey []byte) Store {
+	return redisStore{
+		client: client,
+		cookie: securecookie.New(cookieHashKey, cookieBlockKey),
+		key:    encryptionKey(dataKey),
+	}
+}
+
+func (s redisStore) Save(w http.ResponseWriter, r *http.Request, data Data) error {
+	id, ok := s.cookieValue(r)
+	if !ok {
+		id = newSessionID()
+	}
+
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := s.key.seal(plaintext)
+	if err != nil {
+		return err
+	}
+
+	if err := s.client.Set(r.Context(), redisKeyPrefix+id, ciphertext, redisSessionTTL).Err(); err != nil {
+		return err
+	}
+
+	return s.writeCookie(w, id)
+}
+
+func (s redisStore) Get(r *http.Request) (Data, bool) {
+	id, ok := s.cookieValue(r)
+	if !ok {
+		return Data{}, false
+	}
+
+	ciphertext, err := s.client.Get(r.Context(), redisKeyPrefix+id).Bytes()
+	if err != nil {
+		return Data{}, false
+	}
+
+	plaintext, err := s.key.open(ciphertext)
+	if err != nil {
+		return Data{}, false
+	}
+
+	var data Data
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return Data{}, false
+	}
+
+	return data, true
+}
+
+func (s redisStore) cookieValue(r *http.Request) (string, bool) {
+	c, err := r.Cookie(cookieName)
+	if err != nil {
+		return "", false
+	}
+
+	var id string
+	if err := s.cookie.Decode(cookieName, c.Value, &id); err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+func (s redisStore) writeCookie(w http.ResponseWriter, id string) error {
+	encoded, err := s.cookie.Encode(cookieName, id)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    encoded,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(redisSessionTTL.Seconds()),
+	})
+	return nil
+}
+
+func newSessionID() string {
+	return hex.EncodeToString(securecookie.GenerateRandomKey(32))
+}
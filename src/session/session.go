@@ -0,0 +1,92 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+const cookieName = "spotify_status_session"
+
+// Data is the information the pre-Spotify-callback leg of the login flow
+// needs to hand off to the callback, kept server-side instead of in cookies.
+type Data struct {
+	SlackUserID      string
+	SlackAccessToken string
+}
+
+// Store keeps request-scoped secrets (the Slack user id and access token,
+// today) out of the cookie itself; the cookie only ever carries an opaque,
+// signed session id.
+type Store interface {
+	Save(w http.ResponseWriter, r *http.Request, data Data) error
+	Get(r *http.Request) (Data, bool)
+}
+
+// cookieStore is the default, single-instance Store: the cookie carries
+// nothing but a signed+encrypted random session id, and the payload it
+// resolves to lives in an in-process map. NewRedisStore is the equivalent
+// for a horizontally-scaled deployment.
+type cookieStore struct {
+	store    sessions.Store
+	sessions sync.Map // session id -> Data
+}
+
+// NewCookieStore returns the default Store. secret should come from the
+// SESSION_SECRET env var; it's expanded into a separate HMAC key and AES
+// block key so the session id cookie is both tamper-proof and encrypted,
+// not just signed.
+func NewCookieStore(secret string) Store {
+	hashKey := sha256.Sum256([]byte("spotify-status-session-hash:" + secret))
+	blockKey := sha256.Sum256([]byte("spotify-status-session-block:" + secret))
+
+	return &cookieStore{
+		store: sessions.NewCookieStore(hashKey[:], blockKey[:]),
+	}
+}
+
+func (c *cookieStore) Save(w http.ResponseWriter, r *http.Request, data Data) error {
+	sess, _ := c.store.Get(r, cookieName)
+
+	id, ok := sess.Values["id"].(string)
+	if !ok {
+		id = hex.EncodeToString(securecookie.GenerateRandomKey(32))
+		sess.Values["id"] = id
+	}
+
+	sess.Options = &sessions.Options{
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if err := sess.Save(r, w); err != nil {
+		return err
+	}
+
+	c.sessions.Store(id, data)
+	return nil
+}
+
+func (c *cookieStore) Get(r *http.Request) (Data, bool) {
+	sess, err := c.store.Get(r, cookieName)
+	if err != nil || sess.IsNew {
+		return Data{}, false
+	}
+
+	id, ok := sess.Values["id"].(string)
+	if !ok {
+		return Data{}, false
+	}
+
+	value, ok := c.sessions.Load(id)
+	if !ok {
+		return Data{}, false
+	}
+
+	return value.(Data), true
+}
@@ -0,0 +1,50 @@
+package slackverify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingHeaders = errors.New("slackverify: missing X-Slack-Signature or missing or malformed X-Slack-Request-Timestamp")
+	ErrStaleTimestamp = errors.New("slackverify: request timestamp is more than 5 minutes from the current time")
+	ErrBadSignature   = errors.New("slackverify: signature mismatch")
+)
+
+const maxRequestAge = 5 * time.Minute
+
+// Verify checks a Slack request's v0= HMAC-SHA256 signature, as described in
+// https://api.slack.com/authentication/verifying-requests-from-slack. body
+// must be the raw, unparsed request body bytes.
+func Verify(signingSecret string, r *http.Request, body []byte) error {
+	signature := r.Header.Get("X-Slack-Signature")
+	timestampHeader := r.Header.Get("X-Slack-Request-Timestamp")
+	if signature == "" || timestampHeader == "" {
+		return ErrMissingHeaders
+	}
+
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return ErrMissingHeaders
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > maxRequestAge || age < -maxRequestAge {
+		return ErrStaleTimestamp
+	}
+
+	base := "v0:" + timestampHeader + ":" + string(body)
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte(base))
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(strings.TrimSpace(signature))) {
+		return ErrBadSignature
+	}
+
+	return nil
+}
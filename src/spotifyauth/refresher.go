@@ -0,0 +1,166 @@
+package spotifyauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/o-mago/spotify-status/src/domain"
+	"github.com/o-mago/spotify-status/src/metrics"
+	"golang.org/x/oauth2"
+)
+
+const spotifyTokenURL = "https://accounts.spotify.com/api/token"
+
+// refreshMargin is how close to its expiry a token may get before
+// refreshingTokenSource refreshes it; it matches the margin oauth2.Token.Valid
+// uses, so ReuseTokenSource and refreshingTokenSource agree on a stale token.
+const refreshMargin = 10 * time.Second
+
+// TokenPersister saves a freshly refreshed Spotify token back onto the
+// user, rotating the refresh token whenever Spotify issues a new one.
+type TokenPersister interface {
+	UpdateSpotifyToken(ctx context.Context, user domain.User) error
+}
+
+// TokenRefresher keeps a domain.User's Spotify access token valid, refreshing
+// it against the token endpoint on demand and persisting the result so the
+// refresh only has to happen once per expiry, not once per request.
+type TokenRefresher struct {
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+	persister    TokenPersister
+	logger       *slog.Logger
+}
+
+func NewTokenRefresher(clientID, clientSecret string, persister TokenPersister, logger *slog.Logger) TokenRefresher {
+	return TokenRefresher{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		httpClient:   http.DefaultClient,
+		persister:    persister,
+		logger:       logger,
+	}
+}
+
+// Refresh exchanges the user's refresh token for a new access token,
+// persists the result on the user, and returns the updated user.
+func (t TokenRefresher) Refresh(ctx context.Context, user domain.User) (domain.User, error) {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", user.SpotifyRefreshToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, spotifyTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		metrics.TokenRefreshTotal.WithLabelValues("error").Inc()
+		return domain.User{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Basic "+basicAuth(t.clientID, t.clientSecret))
+
+	outboundStart := time.Now()
+	resp, err := t.httpClient.Do(req)
+	metrics.ObserveOutbound("spotify", outboundStart)
+	if err != nil {
+		metrics.TokenRefreshTotal.WithLabelValues("error").Inc()
+		return domain.User{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		metrics.TokenRefreshTotal.WithLabelValues("error").Inc()
+		return domain.User{}, fmt.Errorf("spotify token refresh failed with status %s", resp.Status)
+	}
+
+	var tokenResp struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		metrics.TokenRefreshTotal.WithLabelValues("error").Inc()
+		return domain.User{}, err
+	}
+
+	user.SpotifyAccessToken = tokenResp.AccessToken
+	user.SpotifyTokenType = tokenResp.TokenType
+	user.SpotifyExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	if tokenResp.RefreshToken != "" {
+		user.SpotifyRefreshToken = tokenResp.RefreshToken
+	}
+
+	if err := t.persister.UpdateSpotifyToken(ctx, user); err != nil {
+		metrics.TokenRefreshTotal.WithLabelValues("error").Inc()
+		return domain.User{}, err
+	}
+
+	metrics.TokenRefreshTotal.WithLabelValues("success").Inc()
+	return user, nil
+}
+
+// tokenSource builds the oauth2.TokenSource backing both Client and Token:
+// it hands back user's current Spotify token as-is until it's actually near
+// expiry, only then calling Refresh (and persisting the result).
+func (t TokenRefresher) tokenSource(ctx context.Context, user domain.User) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &refreshingTokenSource{
+		ctx:       ctx,
+		user:      user,
+		refresher: t,
+	})
+}
+
+// Client returns an *http.Client that transparently refreshes the user's
+// Spotify access token as it nears expiry, persisting each refresh via
+// t.persister.
+func (t TokenRefresher) Client(ctx context.Context, user domain.User) *http.Client {
+	return oauth2.NewClient(ctx, t.tokenSource(ctx, user))
+}
+
+// Token returns a refresh-aware Spotify token for user: the existing token
+// if it isn't near expiry yet, or a refreshed (and persisted) one if it is.
+// It's the same oauth2.TokenSource Client wraps, for callers (like the
+// provider dispatch in /now) that need a token value instead of a client.
+func (t TokenRefresher) Token(ctx context.Context, user domain.User) (*oauth2.Token, error) {
+	return t.tokenSource(ctx, user).Token()
+}
+
+type refreshingTokenSource struct {
+	ctx       context.Context
+	user      domain.User
+	refresher TokenRefresher
+}
+
+func (s *refreshingTokenSource) Token() (*oauth2.Token, error) {
+	if time.Until(s.user.SpotifyExpiry) > refreshMargin {
+		return toOAuth2Token(s.user), nil
+	}
+
+	refreshed, err := s.refresher.Refresh(s.ctx, s.user)
+	if err != nil {
+		return nil, err
+	}
+	s.user = refreshed
+
+	return toOAuth2Token(s.user), nil
+}
+
+func toOAuth2Token(user domain.User) *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  user.SpotifyAccessToken,
+		RefreshToken: user.SpotifyRefreshToken,
+		TokenType:    user.SpotifyTokenType,
+		Expiry:       user.SpotifyExpiry,
+	}
+}
+
+func basicAuth(clientID, clientSecret string) string {
+	return base64.StdEncoding.EncodeToString([]byte(clientID + ":" + clientSecret))
+}
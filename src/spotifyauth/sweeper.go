@@ -0,0 +1,47 @@
+package spotifyauth
+
+import (
+	"context"
+	"time"
+
+	"github.com/o-mago/spotify-status/src/domain"
+)
+
+// ExpiringUserLister lists the users whose Spotify token expires within the
+// given window, so the sweeper can refresh them ahead of the hot path.
+type ExpiringUserLister interface {
+	ListUsersExpiringBefore(ctx context.Context, before time.Time) ([]domain.User, error)
+}
+
+// StartSweeper launches a goroutine that, every interval, pre-refreshes the
+// Spotify tokens of users expiring in the next lookahead window. It runs
+// until ctx is cancelled.
+func (t TokenRefresher) StartSweeper(ctx context.Context, lister ExpiringUserLister, interval, lookahead time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.sweep(ctx, lister, lookahead)
+			}
+		}
+	}()
+}
+
+func (t TokenRefresher) sweep(ctx context.Context, lister ExpiringUserLister, lookahead time.Duration) {
+	users, err := lister.ListUsersExpiringBefore(ctx, time.Now().Add(lookahead))
+	if err != nil {
+		t.logger.Error("spotifyauth: sweeper failed to list expiring users", "err", err)
+		return
+	}
+
+	for _, user := range users {
+		if _, err := t.Refresh(ctx, user); err != nil {
+			t.logger.Error("spotifyauth: sweeper failed to refresh token", "user_id", user.SlackUserID, "err", err)
+		}
+	}
+}